@@ -0,0 +1,176 @@
+// Package ast builds a CSS syntax tree on top of csslex/token, following
+// the parsing algorithms of the CSS Syntax Module Level 3
+// (https://www.w3.org/TR/css-syntax-3/#parsing). It preserves enough detail
+// to inspect the value tokens of a declaration or the prelude of an at-rule
+// such as @media or @keyframes, which the flat item stream from the
+// top-level csslex package cannot express.
+package ast
+
+import "github.com/x1ddos/csslex/token"
+
+// Node is implemented by every type in the syntax tree, so that Walk can
+// traverse a tree without knowing its concrete shape in advance.
+type Node interface {
+	// Children returns this node's direct children, in source order.
+	Children() []Node
+}
+
+// Rule is either a *QualifiedRule or an *AtRule, the two kinds of rule a
+// stylesheet or a rule's block can contain.
+type Rule interface {
+	Node
+	rule()
+}
+
+// Stylesheet is the root of a parsed CSS document: a top-level list of
+// rules, in source order.
+type Stylesheet struct {
+	Rules []Rule
+}
+
+func (s *Stylesheet) Children() []Node {
+	nodes := make([]Node, len(s.Rules))
+	for i, r := range s.Rules {
+		nodes[i] = r
+	}
+	return nodes
+}
+
+// QualifiedRule is a rule whose prelude is not introduced by an at-keyword,
+// e.g. a selector list followed by a declaration block.
+type QualifiedRule struct {
+	// Prelude holds the component values before the block, e.g. the
+	// tokens of a selector list.
+	Prelude []ComponentValue
+
+	// Declarations holds this rule's own declarations, in source order.
+	Declarations []*Declaration
+
+	// Rules holds rules nested inside this rule's block, per CSS Nesting
+	// (https://drafts.csswg.org/css-nesting-1/), e.g. `&:hover { ... }`
+	// or `h2 { ... }` nested inside another rule's block.
+	Rules []Rule
+}
+
+func (r *QualifiedRule) rule() {}
+
+func (r *QualifiedRule) Children() []Node {
+	var nodes []Node
+	for _, cv := range r.Prelude {
+		nodes = append(nodes, cv)
+	}
+	for _, d := range r.Declarations {
+		nodes = append(nodes, d)
+	}
+	for _, nested := range r.Rules {
+		nodes = append(nodes, nested)
+	}
+	return nodes
+}
+
+// AtRule is a rule introduced by an at-keyword, e.g. @media or @import.
+type AtRule struct {
+	// Name is the at-keyword without its leading '@', e.g. "media".
+	Name string
+
+	// Prelude holds the component values between the at-keyword and the
+	// block (or the terminating ';').
+	Prelude []ComponentValue
+
+	// HasBlock reports whether the at-rule has a {} block at all, as
+	// opposed to being terminated by ';', e.g. @import.
+	HasBlock bool
+
+	// Declarations and Rules hold the contents of the at-rule's block,
+	// when it has one. Whether an at-rule's block holds declarations
+	// (@font-face), rules (@media) or both is a matter of the specific
+	// at-rule's grammar, which this generic parser does not know, so it
+	// populates both from whatever the block contains, recognizing a
+	// nested rule the same way CSS Nesting extends "consume a list of
+	// declarations" to allow nested style rules; see QualifiedRule.Rules.
+	Declarations []*Declaration
+	Rules        []Rule
+}
+
+func (r *AtRule) rule() {}
+
+func (r *AtRule) Children() []Node {
+	var nodes []Node
+	for _, cv := range r.Prelude {
+		nodes = append(nodes, cv)
+	}
+	for _, d := range r.Declarations {
+		nodes = append(nodes, d)
+	}
+	for _, nested := range r.Rules {
+		nodes = append(nodes, nested)
+	}
+	return nodes
+}
+
+// Declaration is a single property/value pair, as found in a rule's block,
+// e.g. `color: red !important`.
+type Declaration struct {
+	Property  string
+	Value     []ComponentValue
+	Important bool
+}
+
+func (d *Declaration) Children() []Node {
+	nodes := make([]Node, len(d.Value))
+	for i, cv := range d.Value {
+		nodes[i] = cv
+	}
+	return nodes
+}
+
+// ComponentValue is a single component value as defined by CSS Syntax Level
+// 3: a preserved token, a Function, or a SimpleBlock.
+type ComponentValue interface {
+	Node
+	componentValue()
+}
+
+// TokenValue is a ComponentValue that is just a single preserved token,
+// e.g. an identifier, a number, or a punctuation token such as a comma.
+type TokenValue struct {
+	Token *token.Token
+}
+
+func (v *TokenValue) componentValue()  {}
+func (v *TokenValue) Children() []Node { return nil }
+
+// Function is a ComponentValue for a function call, e.g. `calc(1px + 2%)`.
+// Name excludes the trailing '('; Value holds everything up to, but not
+// including, the matching ')'.
+type Function struct {
+	Name  string
+	Value []ComponentValue
+}
+
+func (f *Function) componentValue() {}
+
+func (f *Function) Children() []Node {
+	nodes := make([]Node, len(f.Value))
+	for i, cv := range f.Value {
+		nodes[i] = cv
+	}
+	return nodes
+}
+
+// SimpleBlock is a ComponentValue for a (), [] or {} block. Open identifies
+// which of the three it is by its opening token type.
+type SimpleBlock struct {
+	Open  token.Type
+	Value []ComponentValue
+}
+
+func (b *SimpleBlock) componentValue() {}
+
+func (b *SimpleBlock) Children() []Node {
+	nodes := make([]Node, len(b.Value))
+	for i, cv := range b.Value {
+		nodes[i] = cv
+	}
+	return nodes
+}
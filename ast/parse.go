@@ -0,0 +1,349 @@
+package ast
+
+import (
+	"strings"
+
+	"github.com/x1ddos/csslex/token"
+)
+
+// Parse implements "parse a stylesheet"
+// (https://www.w3.org/TR/css-syntax-3/#parse-a-css-stylesheet): it consumes
+// a top-level list of rules from input and returns the resulting tree.
+// Parse never fails; malformed constructs are dropped per the error
+// recovery rules of the spec, the same way a browser's CSS parser does.
+func Parse(input string) *Stylesheet {
+	p := &parser{toks: tokenize(input)}
+	return &Stylesheet{Rules: p.consumeRules(true)}
+}
+
+// tokenize materializes Tokenize's iterator into a slice so the parser can
+// freely look ahead and reconsume tokens, which the grammar requires.
+func tokenize(input string) []*token.Token {
+	var toks []*token.Token
+	for tok := range token.Tokenize(input) {
+		toks = append(toks, tok)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+	return toks
+}
+
+// parser walks a fixed slice of Tokens, supporting the "reconsume the
+// current input token" operation the spec's algorithms rely on.
+type parser struct {
+	toks []*token.Token
+	pos  int
+}
+
+// next consumes and returns the next token.
+func (p *parser) next() *token.Token {
+	t := p.toks[p.pos]
+	if t.Type != token.EOF {
+		p.pos++
+	}
+	return t
+}
+
+// reconsume steps back so the next call to next returns t again. It may
+// only be called once per call to next.
+func (p *parser) reconsume() {
+	if p.pos > 0 {
+		p.pos--
+	}
+}
+
+// peekType reports the type of the token next() would return, without
+// consuming it.
+func (p *parser) peekType() token.Type {
+	return p.toks[p.pos].Type
+}
+
+// consumeRules implements "consume a list of rules".
+func (p *parser) consumeRules(topLevel bool) []Rule {
+	var rules []Rule
+	for {
+		t := p.next()
+		switch {
+		case t.Type == token.Whitespace:
+			continue
+		case t.Type == token.EOF:
+			return rules
+		case t.Type == token.CDO || t.Type == token.CDC:
+			if !topLevel {
+				p.reconsume()
+				if r := p.consumeQualifiedRule(); r != nil {
+					rules = append(rules, r)
+				}
+			}
+		case t.Type == token.AtKeyword:
+			p.reconsume()
+			rules = append(rules, p.consumeAtRule())
+		default:
+			p.reconsume()
+			if r := p.consumeQualifiedRule(); r != nil {
+				rules = append(rules, r)
+			}
+		}
+	}
+}
+
+// consumeAtRule implements "consume an at-rule".
+func (p *parser) consumeAtRule() *AtRule {
+	name := p.next().Value
+	rule := &AtRule{Name: name}
+	for {
+		t := p.next()
+		switch t.Type {
+		case token.Semicolon:
+			return rule
+		case token.EOF:
+			return rule
+		case token.LeftBrace:
+			rule.HasBlock = true
+			rule.Declarations, rule.Rules = p.consumeDeclarations()
+			return rule
+		default:
+			p.reconsume()
+			rule.Prelude = append(rule.Prelude, p.consumeComponentValue())
+		}
+	}
+}
+
+// consumeQualifiedRule implements "consume a qualified rule". It returns
+// nil if the input ends before the rule's block starts, per the spec's
+// error handling.
+func (p *parser) consumeQualifiedRule() *QualifiedRule {
+	rule := &QualifiedRule{}
+	for {
+		t := p.next()
+		switch t.Type {
+		case token.EOF:
+			return nil
+		case token.LeftBrace:
+			rule.Declarations, rule.Rules = p.consumeDeclarations()
+			return rule
+		default:
+			p.reconsume()
+			rule.Prelude = append(rule.Prelude, p.consumeComponentValue())
+		}
+	}
+}
+
+// consumeComponentValue implements "consume a component value".
+func (p *parser) consumeComponentValue() ComponentValue {
+	t := p.next()
+	switch t.Type {
+	case token.LeftBrace, token.LeftBracket, token.LeftParen:
+		block := p.consumeSimpleBlock(t.Type)
+		return block
+	case token.Function:
+		return p.consumeFunction(t.Value)
+	default:
+		return &TokenValue{Token: t}
+	}
+}
+
+// blockEnd maps a block's opening token type to the token type that closes
+// it, per the mirror-variant table in
+// https://www.w3.org/TR/css-syntax-3/#consume-simple-block.
+func blockEnd(open token.Type) token.Type {
+	switch open {
+	case token.LeftBracket:
+		return token.RightBracket
+	case token.LeftParen:
+		return token.RightParen
+	default:
+		return token.RightBrace
+	}
+}
+
+// consumeSimpleBlock implements "consume a simple block", given the
+// already-consumed opening token's type.
+func (p *parser) consumeSimpleBlock(open token.Type) *SimpleBlock {
+	end := blockEnd(open)
+	block := &SimpleBlock{Open: open}
+	for {
+		t := p.next()
+		if t.Type == end || t.Type == token.EOF {
+			return block
+		}
+		p.reconsume()
+		block.Value = append(block.Value, p.consumeComponentValue())
+	}
+}
+
+// consumeFunction implements "consume a function", given the function
+// token's name (the tokenizer has already consumed the trailing '(').
+func (p *parser) consumeFunction(name string) *Function {
+	fn := &Function{Name: name}
+	for {
+		t := p.next()
+		if t.Type == token.RightParen || t.Type == token.EOF {
+			return fn
+		}
+		p.reconsume()
+		fn.Value = append(fn.Value, p.consumeComponentValue())
+	}
+}
+
+// consumeDeclarations implements "consume a list of declarations" for a
+// rule's already-opened {} block, mixing declarations, at-rules and nested
+// qualified rules in source order and stopping at the matching '}' (or EOF,
+// on unclosed input). Recognizing a nested qualified rule, e.g. `&:hover {
+// ... }` or `h2 { ... }` inside another rule's block, is the extension CSS
+// Nesting (https://drafts.csswg.org/css-nesting-1/) makes to this
+// algorithm.
+func (p *parser) consumeDeclarations() ([]*Declaration, []Rule) {
+	var decls []*Declaration
+	var rules []Rule
+	for {
+		t := p.next()
+		switch {
+		case t.Type == token.Whitespace || t.Type == token.Semicolon:
+			continue
+		case t.Type == token.RightBrace || t.Type == token.EOF:
+			return decls, rules
+		case t.Type == token.AtKeyword:
+			p.reconsume()
+			rules = append(rules, p.consumeAtRule())
+		case t.Type == token.Ident && p.declarationFollows(t.Value):
+			if d := p.consumeDeclaration(t.Value); d != nil {
+				decls = append(decls, d)
+			}
+		case p.startsNestedRule(t):
+			p.reconsume()
+			if r := p.consumeQualifiedRule(); r != nil {
+				rules = append(rules, r)
+			}
+		case t.Type == token.Ident:
+			if d := p.consumeDeclaration(t.Value); d != nil {
+				decls = append(decls, d)
+			}
+		default:
+			p.reconsume()
+			p.consumeComponentValueUntilDeclEnd()
+		}
+	}
+}
+
+// declarationFollows reports whether prop, the value of an Ident token
+// already consumed by the caller, names a custom property (starts with
+// "--") immediately followed by "ws* ':'". Custom property values are
+// allowed to contain arbitrary tokens, including a top-level {} block, e.g.
+// `--foo: {bar}`, so for these idents the colon check alone decides
+// declaration-ness, rather than startsNestedRule's scan for an unmatched
+// '{', which would otherwise misidentify such a value as a nested rule.
+// Ordinary idents, e.g. the "a" in a selector like `a:hover { ... }`, are
+// left to startsNestedRule instead: a bare IDENT-COLON check can't tell
+// that case apart from a declaration, since both start the same way.
+func (p *parser) declarationFollows(prop string) bool {
+	if !strings.HasPrefix(prop, "--") {
+		return false
+	}
+	i := p.pos
+	for i < len(p.toks) && p.toks[i].Type == token.Whitespace {
+		i++
+	}
+	return i < len(p.toks) && p.toks[i].Type == token.Colon
+}
+
+// startsNestedRule reports whether first, the token just returned by next,
+// begins a nested qualified rule rather than a declaration: whether the run
+// of tokens up to the next ';', '}' or EOF token is terminated by '{'. The
+// scan is flat: a ';', '{' or '}' token inside a () or [] block counts too.
+// It is only consulted once declarationFollows has ruled out first being a
+// custom property, so it doesn't need to itself worry about that case.
+func (p *parser) startsNestedRule(first *token.Token) bool {
+	switch first.Type {
+	case token.LeftBrace:
+		return true
+	case token.Semicolon, token.RightBrace, token.EOF:
+		return false
+	}
+	for i := p.pos; i < len(p.toks); i++ {
+		switch p.toks[i].Type {
+		case token.LeftBrace:
+			return true
+		case token.Semicolon, token.RightBrace, token.EOF:
+			return false
+		}
+	}
+	return false
+}
+
+// consumeDeclaration implements "consume a declaration": prop is the
+// already-consumed property-name token's value. It returns nil, having
+// consumed and discarded the rest of the malformed declaration, if prop
+// isn't followed by a colon.
+func (p *parser) consumeDeclaration(prop string) *Declaration {
+	for p.peekType() == token.Whitespace {
+		p.next()
+	}
+	if p.peekType() != token.Colon {
+		p.consumeComponentValueUntilDeclEnd()
+		return nil
+	}
+	p.next() // colon
+	for p.peekType() == token.Whitespace {
+		p.next()
+	}
+	d := &Declaration{Property: prop}
+	for {
+		t := p.peekType()
+		if t == token.Semicolon || t == token.RightBrace || t == token.EOF {
+			break
+		}
+		d.Value = append(d.Value, p.consumeComponentValue())
+	}
+	d.Value, d.Important = stripImportant(d.Value)
+	return d
+}
+
+// consumeComponentValueUntilDeclEnd consumes and discards component values
+// up to (but not including) the next ';', '}' or EOF, for error recovery
+// when a declaration is malformed or a top-level token isn't a declaration.
+func (p *parser) consumeComponentValueUntilDeclEnd() {
+	for {
+		t := p.peekType()
+		if t == token.Semicolon || t == token.RightBrace || t == token.EOF {
+			return
+		}
+		p.consumeComponentValue()
+	}
+}
+
+// stripImportant trims trailing whitespace from value and, if what remains
+// ends in "! important" (whitespace allowed around the '!'), removes that
+// too and reports important as true.
+func stripImportant(value []ComponentValue) (rest []ComponentValue, important bool) {
+	trimmed := trimTrailingWhitespace(value)
+	v := trimmed
+	if len(v) < 2 {
+		return v, false
+	}
+	ident, ok := v[len(v)-1].(*TokenValue)
+	if !ok || ident.Token.Type != token.Ident || !strings.EqualFold(ident.Token.Value, "important") {
+		return v, false
+	}
+	v = trimTrailingWhitespace(v[:len(v)-1])
+	if len(v) == 0 {
+		return trimmed, false
+	}
+	bang, ok := v[len(v)-1].(*TokenValue)
+	if !ok || bang.Token.Type != token.Delim || bang.Token.Value != "!" {
+		return trimmed, false
+	}
+	return trimTrailingWhitespace(v[:len(v)-1]), true
+}
+
+func trimTrailingWhitespace(value []ComponentValue) []ComponentValue {
+	for len(value) > 0 {
+		tv, ok := value[len(value)-1].(*TokenValue)
+		if !ok || tv.Token.Type != token.Whitespace {
+			break
+		}
+		value = value[:len(value)-1]
+	}
+	return value
+}
@@ -0,0 +1,134 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/x1ddos/csslex/token"
+)
+
+func TestParseQualifiedRule(t *testing.T) {
+	s := Parse(`a.c1 { color: red; width: 10px !important }`)
+	if len(s.Rules) != 1 {
+		t.Fatalf("len(s.Rules) = %d; want 1", len(s.Rules))
+	}
+	r, ok := s.Rules[0].(*QualifiedRule)
+	if !ok {
+		t.Fatalf("s.Rules[0] = %T; want *QualifiedRule", s.Rules[0])
+	}
+	if len(r.Prelude) == 0 {
+		t.Error("r.Prelude is empty; want the selector tokens")
+	}
+	if len(r.Declarations) != 2 {
+		t.Fatalf("len(r.Declarations) = %d; want 2", len(r.Declarations))
+	}
+	if d := r.Declarations[0]; d.Property != "color" || d.Important {
+		t.Errorf("Declarations[0] = %+v; want color:red, not important", d)
+	}
+	if d := r.Declarations[1]; d.Property != "width" || !d.Important {
+		t.Errorf("Declarations[1] = %+v; want width, important", d)
+	}
+}
+
+func TestParseAtRuleWithoutBlock(t *testing.T) {
+	s := Parse(`@import url(style.css);`)
+	if len(s.Rules) != 1 {
+		t.Fatalf("len(s.Rules) = %d; want 1", len(s.Rules))
+	}
+	r, ok := s.Rules[0].(*AtRule)
+	if !ok {
+		t.Fatalf("s.Rules[0] = %T; want *AtRule", s.Rules[0])
+	}
+	if r.Name != "import" || r.HasBlock {
+		t.Errorf("r = %+v; want Name=import, HasBlock=false", r)
+	}
+	if len(r.Prelude) == 0 {
+		t.Error("r.Prelude is empty; want the url(...) component value")
+	}
+}
+
+func TestParseAtRuleWithBlock(t *testing.T) {
+	s := Parse(`@font-face { font-family: "Foo"; src: url(foo.woff) }`)
+	r, ok := s.Rules[0].(*AtRule)
+	if !ok {
+		t.Fatalf("s.Rules[0] = %T; want *AtRule", s.Rules[0])
+	}
+	if !r.HasBlock || len(r.Declarations) != 2 {
+		t.Errorf("r = %+v; want HasBlock=true, 2 declarations", r)
+	}
+}
+
+func TestParseMalformedDeclaration(t *testing.T) {
+	s := Parse(`a { ; color ; color: blue }`)
+	r := s.Rules[0].(*QualifiedRule)
+	if len(r.Declarations) != 1 || r.Declarations[0].Property != "color" {
+		t.Errorf("r.Declarations = %+v; want a single color:blue declaration", r.Declarations)
+	}
+}
+
+func TestParseAtRuleWithNestedRule(t *testing.T) {
+	s := Parse(`@media print { body { color: red } }`)
+	r := s.Rules[0].(*AtRule)
+	if len(r.Rules) != 1 {
+		t.Fatalf("len(r.Rules) = %d; want 1", len(r.Rules))
+	}
+	nested := r.Rules[0].(*QualifiedRule)
+	if len(nested.Declarations) != 1 || nested.Declarations[0].Property != "color" {
+		t.Errorf("nested.Declarations = %+v; want a single color declaration", nested.Declarations)
+	}
+}
+
+func TestParseNestedRule(t *testing.T) {
+	s := Parse(`.card { color: red; &:hover { color: blue } h2 { font-weight: bold } }`)
+	r := s.Rules[0].(*QualifiedRule)
+	if len(r.Declarations) != 1 || len(r.Rules) != 2 {
+		t.Fatalf("r = %+v; want 1 declaration and 2 nested rules", r)
+	}
+	for i, want := range []string{"color", "font-weight"} {
+		nested := r.Rules[i].(*QualifiedRule)
+		if len(nested.Declarations) != 1 || nested.Declarations[0].Property != want {
+			t.Errorf("r.Rules[%d].Declarations = %+v; want a single %s declaration", i, nested.Declarations, want)
+		}
+	}
+}
+
+func TestParseDeclarationWithBlockValue(t *testing.T) {
+	s := Parse(`a { --foo: {bar}; color: red }`)
+	r := s.Rules[0].(*QualifiedRule)
+	if len(r.Rules) != 0 || len(r.Declarations) != 2 {
+		t.Fatalf("r = %+v; want 0 nested rules and 2 declarations", r)
+	}
+	d := r.Declarations[0]
+	if d.Property != "--foo" || len(d.Value) != 1 {
+		t.Fatalf("Declarations[0] = %+v; want --foo with a single value", d)
+	}
+	block, ok := d.Value[0].(*SimpleBlock)
+	if !ok || block.Open != token.LeftBrace {
+		t.Errorf("Declarations[0].Value[0] = %+v; want SimpleBlock({)", d.Value[0])
+	}
+	if p := r.Declarations[1].Property; p != "color" {
+		t.Errorf("Declarations[1].Property = %q; want color", p)
+	}
+}
+
+func TestParseFunctionAndBlock(t *testing.T) {
+	s := Parse(`a { width: calc(100% - 10px); grid: [full] auto }`)
+	r := s.Rules[0].(*QualifiedRule)
+	fn, ok := r.Declarations[0].Value[0].(*Function)
+	if !ok || fn.Name != "calc" {
+		t.Fatalf("Declarations[0].Value[0] = %+v; want Function calc", r.Declarations[0].Value[0])
+	}
+	block, ok := r.Declarations[1].Value[0].(*SimpleBlock)
+	if !ok || block.Open != token.LeftBracket {
+		t.Fatalf("Declarations[1].Value[0] = %+v; want SimpleBlock([)", r.Declarations[1].Value[0])
+	}
+}
+
+func TestParseUnclosedInput(t *testing.T) {
+	// Parse never fails outright; an unclosed rule is simply cut off at
+	// EOF instead of panicking or hanging.
+	s := Parse(`a { color: red`)
+	r := s.Rules[0].(*QualifiedRule)
+	if len(r.Declarations) != 1 {
+		t.Errorf("r.Declarations = %+v; want 1 declaration", r.Declarations)
+	}
+}
@@ -0,0 +1,204 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/x1ddos/csslex/token"
+)
+
+// Print serializes s back to CSS, writing to w. The output is a best-effort
+// reconstruction: it is semantically equivalent to the parsed input, but not
+// necessarily byte-for-byte identical, since the tree does not retain
+// original whitespace runs, string quote style or escape sequences.
+func Print(w io.Writer, s *Stylesheet) error {
+	p := &printer{w: w}
+	for _, r := range s.Rules {
+		p.rule(r)
+	}
+	return p.err
+}
+
+// printer writes CSS text to w, latching the first error encountered so
+// callers don't need to check every intermediate write.
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) str(s string) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = io.WriteString(p.w, s)
+}
+
+func (p *printer) rule(r Rule) {
+	switch v := r.(type) {
+	case *QualifiedRule:
+		p.componentValues(v.Prelude)
+		p.str(" {")
+		p.declarationsAndRules(v.Declarations, v.Rules)
+		p.str("}")
+	case *AtRule:
+		p.str("@" + v.Name)
+		if len(v.Prelude) > 0 {
+			p.str(" ")
+			p.componentValues(v.Prelude)
+		}
+		if !v.HasBlock {
+			p.str(";")
+			return
+		}
+		p.str(" {")
+		p.declarationsAndRules(v.Declarations, v.Rules)
+		p.str("}")
+	default:
+		p.err = fmt.Errorf("ast: unknown Rule type %T", r)
+	}
+}
+
+func (p *printer) declarationsAndRules(decls []*Declaration, rules []Rule) {
+	for _, d := range decls {
+		p.str(" ")
+		p.declaration(d)
+		p.str(";")
+	}
+	for _, r := range rules {
+		p.str(" ")
+		p.rule(r)
+	}
+	if len(decls) > 0 || len(rules) > 0 {
+		p.str(" ")
+	}
+}
+
+func (p *printer) declaration(d *Declaration) {
+	p.str(d.Property)
+	p.str(": ")
+	p.componentValues(d.Value)
+	if d.Important {
+		p.str(" !important")
+	}
+}
+
+func (p *printer) componentValues(values []ComponentValue) {
+	for _, v := range values {
+		p.componentValue(v)
+	}
+}
+
+func (p *printer) componentValue(v ComponentValue) {
+	switch cv := v.(type) {
+	case *TokenValue:
+		p.token(cv.Token)
+	case *Function:
+		p.str(cv.Name + "(")
+		p.componentValues(cv.Value)
+		p.str(")")
+	case *SimpleBlock:
+		open, close := blockChars(cv.Open)
+		p.str(open)
+		p.componentValues(cv.Value)
+		p.str(close)
+	default:
+		p.err = fmt.Errorf("ast: unknown ComponentValue type %T", v)
+	}
+}
+
+// blockChars returns the opening and closing delimiters of a SimpleBlock,
+// given its Open token type.
+func blockChars(open token.Type) (string, string) {
+	switch open {
+	case token.LeftBracket:
+		return "[", "]"
+	case token.LeftParen:
+		return "(", ")"
+	default:
+		return "{", "}"
+	}
+}
+
+// token writes t's canonical CSS text. It is a best-effort reconstruction,
+// e.g. a String token is always re-quoted with double quotes regardless of
+// how it was written in the source.
+func (p *printer) token(t *token.Token) {
+	switch t.Type {
+	case token.Ident:
+		p.str(t.Value)
+	case token.AtKeyword:
+		p.str("@" + t.Value)
+	case token.Hash:
+		p.str("#" + t.Value)
+	case token.String, token.BadString:
+		p.str(`"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(t.Value) + `"`)
+	case token.URL:
+		p.str("url(" + t.Value + ")")
+	case token.BadURL:
+		p.str("url(" + t.Value + ")")
+	case token.Delim:
+		p.str(t.Value)
+	case token.Number:
+		p.str(formatNumber(t.Number, t.IsInt))
+	case token.Percentage:
+		p.str(formatNumber(t.Number, t.IsInt) + "%")
+	case token.Dimension:
+		p.str(formatNumber(t.Number, t.IsInt) + t.Unit)
+	case token.UnicodeRange:
+		if t.RangeStart == t.RangeEnd {
+			p.str(fmt.Sprintf("U+%X", t.RangeStart))
+		} else {
+			p.str(fmt.Sprintf("U+%X-%X", t.RangeStart, t.RangeEnd))
+		}
+	case token.IncludeMatch:
+		p.str("~=")
+	case token.DashMatch:
+		p.str("|=")
+	case token.PrefixMatch:
+		p.str("^=")
+	case token.SuffixMatch:
+		p.str("$=")
+	case token.SubstringMatch:
+		p.str("*=")
+	case token.Column:
+		p.str("||")
+	case token.Whitespace:
+		p.str(" ")
+	case token.CDO:
+		p.str("<!--")
+	case token.CDC:
+		p.str("-->")
+	case token.Colon:
+		p.str(":")
+	case token.Semicolon:
+		p.str(";")
+	case token.Comma:
+		p.str(",")
+	case token.LeftBracket:
+		p.str("[")
+	case token.RightBracket:
+		p.str("]")
+	case token.LeftParen:
+		p.str("(")
+	case token.RightParen:
+		p.str(")")
+	case token.LeftBrace:
+		p.str("{")
+	case token.RightBrace:
+		p.str("}")
+	default:
+		p.err = fmt.Errorf("ast: unknown Token type %v", t.Type)
+	}
+}
+
+// formatNumber renders a Number/Percentage/Dimension token's value the way
+// it would most commonly appear in source: without a fractional part when
+// the token was written as an integer.
+func formatNumber(n float64, isInt bool) string {
+	if isInt {
+		return strconv.FormatInt(int64(n), 10)
+	}
+	return strconv.FormatFloat(n, 'g', -1, 64)
+}
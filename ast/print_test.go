@@ -0,0 +1,38 @@
+package ast
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrintRoundTrip(t *testing.T) {
+	tests := []string{
+		`a.c1 { color: red; }`,
+		`@import url(style.css);`,
+		`@font-face { font-family: "Foo"; }`,
+	}
+	for _, css := range tests {
+		var buf bytes.Buffer
+		if err := Print(&buf, Parse(css)); err != nil {
+			t.Errorf("Print(%q) error: %v", css, err)
+			continue
+		}
+		// Printing is best-effort, not byte-identical; check that the
+		// output reparses to an equivalent tree instead of comparing text.
+		got, want := Parse(buf.String()), Parse(css)
+		if len(got.Rules) != len(want.Rules) {
+			t.Errorf("Print(%q) = %q, reparses to %d rules; want %d", css, buf.String(), len(got.Rules), len(want.Rules))
+		}
+	}
+}
+
+func TestPrintDeclarationImportant(t *testing.T) {
+	var buf bytes.Buffer
+	css := `a { width: 1px !important }`
+	if err := Print(&buf, Parse(css)); err != nil {
+		t.Fatalf("Print error: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("!important")) {
+		t.Errorf("Print(%q) = %q; want it to contain !important", css, buf.String())
+	}
+}
@@ -0,0 +1,43 @@
+package ast
+
+// Visitor's Visit method is invoked for each node encountered by Walk. If
+// the result w is not nil, Walk visits n's children with w, then calls
+// w.Visit(nil) once all of them have been visited.
+type Visitor interface {
+	Visit(n Node) (w Visitor)
+}
+
+// Walk traverses a syntax tree in depth-first order, starting at n: it calls
+// v.Visit(n), then recurses into each of n's Children with the Visitor
+// returned by that call, unless it is nil.
+func Walk(v Visitor, n Node) {
+	if n == nil {
+		return
+	}
+	v = v.Visit(n)
+	if v == nil {
+		return
+	}
+	for _, c := range n.Children() {
+		Walk(v, c)
+	}
+	v.Visit(nil)
+}
+
+// inspector adapts a plain func into a Visitor, the way ast.Inspect does in
+// the standard library's go/ast package.
+type inspector func(Node) bool
+
+func (f inspector) Visit(n Node) Visitor {
+	if f(n) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a syntax tree in depth-first order, starting at n: it
+// calls f(n), then recurses into each of n's Children if f returned true.
+// Like Walk, it also calls f(nil) at the end of each subtree.
+func Inspect(n Node, f func(Node) bool) {
+	Walk(inspector(f), n)
+}
@@ -0,0 +1,41 @@
+package ast
+
+import "testing"
+
+func TestInspectCountsDeclarations(t *testing.T) {
+	s := Parse(`a { color: red } b { width: 1px; height: 2px }`)
+	var decls int
+	Inspect(s, func(n Node) bool {
+		if _, ok := n.(*Declaration); ok {
+			decls++
+		}
+		return true
+	})
+	if decls != 3 {
+		t.Errorf("decls = %d; want 3", decls)
+	}
+}
+
+func TestWalkVisitsNilAfterChildren(t *testing.T) {
+	s := Parse(`a { color: red }`)
+	var order []bool // false = entering a node, true = leaving one
+	var v visitFunc
+	v = func(n Node) Visitor {
+		if n == nil {
+			order = append(order, true)
+			return nil
+		}
+		order = append(order, false)
+		return v
+	}
+	Walk(v, s)
+	if len(order) == 0 || order[len(order)-1] != true {
+		t.Errorf("order = %v; want a trailing leave for the root node", order)
+	}
+}
+
+// visitFunc adapts a plain func to a Visitor, for tests that need to
+// observe both the entering and leaving of a node, unlike Inspect.
+type visitFunc func(Node) Visitor
+
+func (f visitFunc) Visit(n Node) Visitor { return f(n) }
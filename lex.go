@@ -14,6 +14,7 @@ package csslex
 
 import (
 	"fmt"
+	"iter"
 	"strings"
 	"unicode/utf8"
 )
@@ -36,73 +37,227 @@ const (
 
 const eof = -1
 
+// Position locates a byte in CSS source by its 1-based line and column, in
+// addition to the raw byte offset also available on its own as Item.Pos.
+// Line and column are computed per the line terminators of CSS Syntax
+// Level 3 section 3.3
+// (https://www.w3.org/TR/css-syntax-3/#input-preprocessing): "\n", "\r",
+// "\f" and "\r\n" (counted as a single terminator) all start a new line.
+type Position struct {
+	Pos  int
+	Line int
+	Col  int
+}
+
 // Item is an atom of lexing process.
 type Item struct {
 	Typ ItemType
-	Pos int
+
+	// Position is the item's starting position. It is embedded so that
+	// item.Pos keeps working as a plain byte offset, for backward
+	// compatibility with code written before Line and Col existed.
+	Position
+
+	// End is the byte offset one past the last byte of Val, i.e.
+	// input[Pos:End] == Val for every Item except ItemError, whose End is
+	// simply where the lexer had scanned to when the error was detected.
+	End int
+
 	Val string
 }
 
-// Lex creates a new lexer and returns channel which will be sent Item tokens.
-// The lexing is started in a goroutine right away, before returing
-// from this method.
+// Lex creates a new lexer and returns a channel to which Item tokens are
+// sent as they are lexed. The lexing is started in a goroutine right away,
+// before returning from this method.
+//
+// Lex is kept for backward compatibility. Prefer LexSeq, or Lexer directly,
+// which lex synchronously and don't leak a goroutine if the caller stops
+// reading before EOF.
 func Lex(input string) chan *Item {
-	l := &lexer{
-		input: input,
-		items: make(chan *Item),
+	items := make(chan *Item)
+	go func() {
+		defer close(items)
+		l := NewLexer(input)
+		for {
+			item, ok := l.Next()
+			if !ok {
+				return
+			}
+			items <- &item
+		}
+	}()
+	return items
+}
+
+// LexSeq returns a pull-based iterator over the Item tokens of input. Unlike
+// Lex, it does no work until driven and never spawns a goroutine, so a range
+// loop that stops early leaves nothing running in the background.
+func LexSeq(input string) iter.Seq[Item] {
+	return LexSeqOptions(input, LexOptions{})
+}
+
+// LexSeqOptions is like LexSeq but with explicit LexOptions.
+func LexSeqOptions(input string, opts LexOptions) iter.Seq[Item] {
+	return func(yield func(Item) bool) {
+		l := NewLexerOptions(input, opts)
+		for {
+			item, ok := l.Next()
+			if !ok || !yield(item) {
+				return
+			}
+		}
 	}
-	go l.run()
-	return l.items
 }
 
-// lexer is the parser state.
-type lexer struct {
-	input              string
-	start, pos         int
-	inBlock, inAtBlock bool
-	state              stateFn
-	items              chan *Item
+// LexOptions configures the behavior of a Lexer.
+type LexOptions struct {
+	// Recover, if true, makes the lexer emit an ItemError and
+	// resynchronize past a malformed construct instead of stopping at
+	// the first one, so callers such as linters or editor tooling see
+	// every problem in the input instead of just the first. Without
+	// Recover, an ItemError is always the last Item produced.
+	Recover bool
+}
+
+// Lexer is a pull-based CSS lexer: callers drive it by calling Next, with
+// no goroutine or channel involved. It is the type Lex and LexSeq are built
+// on top of, for callers that want to interleave lexing with their own
+// control flow.
+type Lexer struct {
+	input      string
+	start, pos int
+
+	// startLine, startCol are the line and column of input[start], i.e.
+	// the position the next emitted Item will start at.
+	startLine, startCol int
+
+	// line, col are the line and column of input[pos], i.e. where the
+	// lexer is currently positioned.
+	line, col int
+
+	// afterCR reports whether the last rune returned by next was '\r', so
+	// that a following '\n' is not counted as a second line terminator.
+	afterCR bool
+
+	// prevLine, prevCol and prevAfterCR hold line, col and afterCR as of
+	// just before the last call to next, so backup (called at most once
+	// per next) can restore them exactly, even across a line terminator.
+	prevLine, prevCol int
+	prevAfterCR       bool
+
+	// blockDepth counts nested {} blocks, so lexBlock can tell when it has
+	// returned to the outermost block of a rule; see css-nesting-1
+	// (https://drafts.csswg.org/css-nesting-1/), e.g. `.card { &:hover {} }`.
+	blockDepth int
+	inAtBlock  bool
+
+	nextFn stateFn // state function to run on the next step
+	state  stateFn // state function run by the previous step; lexComment resumes it
+
+	// queue holds items emitted by a state function but not yet returned
+	// from Next: a single step can emit more than one item, e.g.
+	// lexSelector emits ItemSelector and then ItemBlockStart.
+	queue []Item
+
+	opts LexOptions
+}
+
+// NewLexer creates a Lexer ready to scan input.
+func NewLexer(input string) *Lexer {
+	return NewLexerOptions(input, LexOptions{})
 }
 
-// run lexes the input by executing state functions until the state is nil.
-func (l *lexer) run() {
-	for state := lexAny; state != nil; {
-		prev := state
-		state = state(l)
+// NewLexerOptions is like NewLexer but with explicit LexOptions.
+func NewLexerOptions(input string, opts LexOptions) *Lexer {
+	return &Lexer{
+		input:     input,
+		nextFn:    lexAny,
+		opts:      opts,
+		line:      1,
+		col:       1,
+		startLine: 1,
+		startCol:  1,
+	}
+}
+
+// Next runs the lexer forward, if needed, and returns the next Item. It
+// returns ok == false once the input is exhausted, matching the point at
+// which Lex would close its channel. Without LexOptions.Recover that is
+// right after the first ItemError; with Recover, lexing continues past an
+// ItemError unless the error was detected at EOF.
+func (l *Lexer) Next() (item Item, ok bool) {
+	for len(l.queue) == 0 && l.nextFn != nil {
+		prev := l.nextFn
+		l.nextFn = prev(l)
 		l.state = prev
 	}
-	close(l.items)
+	if len(l.queue) == 0 {
+		return Item{}, false
+	}
+	item, l.queue = l.queue[0], l.queue[1:]
+	return item, true
 }
 
-// emit passes an item back to the client.
-func (l *lexer) emit(t ItemType) {
-	i := &Item{t, l.start, strings.Trim(l.input[l.start:l.pos], spaceChars)}
-	l.items <- i
-	l.start = l.pos
+// emit queues an item to be returned from a future Next call.
+func (l *Lexer) emit(t ItemType) {
+	raw := l.input[l.start:l.pos]
+	val := strings.Trim(raw, spaceChars)
+	end := l.pos - (len(raw) - len(strings.TrimRight(raw, spaceChars)))
+	l.queue = append(l.queue, Item{
+		Typ:      t,
+		Position: Position{Pos: l.start, Line: l.startLine, Col: l.startCol},
+		End:      end,
+		Val:      val,
+	})
+	l.ignore()
 }
 
-// next returns the next rune in the input.
-func (l *lexer) next() rune {
+// next returns the next rune in the input, advancing line and col per the
+// line-terminator rules of CSS Syntax Level 3 section 3.3.
+func (l *Lexer) next() rune {
 	if int(l.pos) >= len(l.input) {
 		return eof
 	}
 	r, w := utf8.DecodeRuneInString(l.input[l.pos:])
 	l.pos += w
+	l.prevLine, l.prevCol, l.prevAfterCR = l.line, l.col, l.afterCR
+	switch {
+	case r == '\n' && l.afterCR:
+		// The second half of a \r\n pair already counted by the \r.
+	case r == '\n' || r == '\r' || r == '\f':
+		l.line++
+		l.col = 1
+	default:
+		l.col++
+	}
+	l.afterCR = r == '\r'
 	return r
 }
 
 // backup steps back one rune. Can only be called once per call of next.
-func (l *lexer) backup() {
+func (l *Lexer) backup() {
 	l.pos--
+	l.line, l.col, l.afterCR = l.prevLine, l.prevCol, l.prevAfterCR
 }
 
 // ignore skips over the pending input before this point
-func (l *lexer) ignore() {
+func (l *Lexer) ignore() {
 	l.start = l.pos
+	l.startLine, l.startCol = l.line, l.col
+}
+
+// advanceTo moves pos forward to target by repeated calls to next, so line,
+// col and afterCR stay correct for callers that would otherwise jump ahead
+// by setting pos directly, e.g. past a comment body that may itself span
+// multiple lines.
+func (l *Lexer) advanceTo(target int) {
+	for l.pos < target {
+		l.next()
+	}
 }
 
 // ignoreSpace consumes a run of runes from spaceChars.
-func (l *lexer) ignoreSpace() {
+func (l *Lexer) ignoreSpace() {
 	for strings.IndexRune(spaceChars, l.next()) >= 0 {
 	}
 	l.backup()
@@ -110,7 +265,7 @@ func (l *lexer) ignoreSpace() {
 }
 
 // untilRun consumes runes until one of the chars is encountered.
-func (l *lexer) untilRun(chars string) rune {
+func (l *Lexer) untilRun(chars string) rune {
 	var r rune
 	for r != eof && strings.IndexRune(chars, r) < 0 {
 		r = l.next()
@@ -119,11 +274,118 @@ func (l *lexer) untilRun(chars string) rune {
 	return r
 }
 
-// errorf returns an error token and terminates the scan by passing
-// back a nil pointer that will be the next state, terminating l.nextItem.
-func (l *lexer) errorf(format string, args ...interface{}) stateFn {
-	l.items <- &Item{ItemError, l.start, fmt.Sprintf(format, args...)}
-	return nil
+// untilRunOutsideNested is like untilRun, except chars found inside a
+// quoted string or parenthesized function argument, e.g. the ';' in
+// url(data:image/png;base64,...) or in a quoted content value, don't count;
+// only occurrences outside of any such nesting stop the scan. If skipBraces
+// is true, a balanced top-level {}, e.g. the one in a custom property's
+// `--foo: {bar};` value, is skipped over rather than stopping the scan, and
+// an unmatched '}' always stops the scan, whether or not it's in chars —
+// callers that pass true use it only once they've independently determined
+// the run being scanned is a declaration, never a nested rule.
+func (l *Lexer) untilRunOutsideNested(chars string, skipBraces bool) rune {
+	var quote rune
+	parenDepth, braceDepth := 0, 0
+	for {
+		r := l.next()
+		switch {
+		case r == eof:
+			l.backup()
+			return eof
+		case quote != 0:
+			switch r {
+			case '\\':
+				l.next() // skip the escaped char, e.g. the '"' in "a\"b"
+			case quote:
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '(':
+			parenDepth++
+		case r == ')':
+			if parenDepth > 0 {
+				parenDepth--
+			}
+		case parenDepth > 0:
+			// Inside a function argument; nothing else stops the scan.
+		case skipBraces && r == openBlock:
+			braceDepth++
+		case skipBraces && r == closeBlock:
+			if braceDepth > 0 {
+				braceDepth--
+			} else {
+				l.backup()
+				return r
+			}
+		case braceDepth == 0 && strings.IndexRune(chars, r) >= 0:
+			l.backup()
+			return r
+		}
+	}
+}
+
+// declColonAt reports whether the input starting at pos is a custom
+// property: an identifier starting with "--", followed by optional
+// whitespace, then ':'. Custom property values are allowed to contain
+// arbitrary tokens, including a top-level {} block, e.g. `--foo: {bar};`,
+// so for these the colon check alone decides declaration-ness, rather than
+// the usual "does the run hit '{' before ';'/'}'" scan, which would
+// otherwise misidentify such a value as a nested rule. Ordinary
+// identifiers, e.g. the "a" in a selector like `a:hover {...}`, are left to
+// that scan instead: a bare ident-colon check can't tell that case apart
+// from a declaration, since both start the same way.
+func (l *Lexer) declColonAt(pos int) bool {
+	i, n := pos, len(l.input)
+	if !strings.HasPrefix(l.input[i:], "--") {
+		return false
+	}
+	start := i
+	for i < n && isIdentByte(l.input[i]) {
+		i++
+	}
+	if i == start {
+		return false
+	}
+	for i < n && strings.IndexByte(spaceChars, l.input[i]) >= 0 {
+		i++
+	}
+	return i < n && l.input[i] == ruleSep
+}
+
+func isIdentByte(b byte) bool {
+	return b == '-' || b == '_' ||
+		(b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') ||
+		b >= utf8.RuneSelf
+}
+
+// errorf queues an ItemError. If Recover is set, it returns resync so
+// lexing continues past the malformed construct; resync is nil if the
+// error was only detectable at EOF, where there is nothing left to
+// resynchronize to. Without Recover, errorf always returns nil, ending the
+// scan once this item is delivered, regardless of resync.
+func (l *Lexer) errorf(resync stateFn, format string, args ...interface{}) stateFn {
+	l.queue = append(l.queue, Item{
+		Typ:      ItemError,
+		Position: Position{Pos: l.start, Line: l.startLine, Col: l.startCol},
+		End:      l.pos,
+		Val:      fmt.Sprintf(format, args...),
+	})
+	if !l.opts.Recover {
+		return nil
+	}
+	return resync
+}
+
+// resyncDeclOrAtRule skips to just past the next ';' or '}' and resumes
+// lexing from lexAny, recovering from a malformed declaration or at-rule.
+func resyncDeclOrAtRule(l *Lexer) stateFn {
+	if l.untilRun(";}") == eof {
+		return nil
+	}
+	l.next()
+	l.ignoreSpace()
+	return lexAny
 }
 
 const (
@@ -137,10 +399,10 @@ const (
 	spaceChars   = " \t\n\r"
 )
 
-type stateFn func(*lexer) stateFn
+type stateFn func(*Lexer) stateFn
 
 // lexAny is the starting point of lexing.
-func lexAny(l *lexer) stateFn {
+func lexAny(l *Lexer) stateFn {
 	for {
 		r := l.next()
 		if r == eof {
@@ -150,7 +412,7 @@ func lexAny(l *lexer) stateFn {
 			l.ignore()
 			continue
 		}
-		if r == closeBlock && l.inAtBlock && !l.inBlock {
+		if r == closeBlock && l.inAtBlock && l.blockDepth == 0 {
 			l.inAtBlock = false
 			l.ignore()
 			l.emit(ItemAtRuleBlockEnd)
@@ -168,21 +430,24 @@ func lexAny(l *lexer) stateFn {
 }
 
 // lexComment parsers CSS comments.
-func lexComment(l *lexer) stateFn {
-	l.pos += len(openComment)
+func lexComment(l *Lexer) stateFn {
+	l.advanceTo(l.pos + len(openComment))
 	i := strings.Index(l.input[l.pos:], closeComment)
 	if i < 0 {
-		return l.errorf("unclosed comment")
+		// There is no closeComment anywhere in the rest of input, so this
+		// error is only ever detected at EOF; there is nothing to
+		// resynchronize to.
+		return l.errorf(nil, "unclosed comment")
 	}
-	l.pos += i + len(closeComment)
+	l.advanceTo(l.pos + i + len(closeComment))
 	l.ignore()
 	return l.state
 }
 
 // lexSelector parses CSS selectors. It emits each one separately,
 // even if they describe the same block, i.e. separated by a comma.
-func lexSelector(l *lexer) stateFn {
-	r := l.untilRun(",{")
+func lexSelector(l *Lexer) stateFn {
+	r := l.untilRunOutsideNested(",{", false)
 	if r == eof {
 		return nil
 	}
@@ -194,49 +459,82 @@ func lexSelector(l *lexer) stateFn {
 	if r == selectorSep {
 		return lexSelector
 	}
-	l.inBlock = true
+	l.blockDepth++
 	l.emit(ItemBlockStart)
 	return lexBlock
 }
 
-// lexBlock parses CSS blocks found in curly braces.
-func lexBlock(l *lexer) stateFn {
-	r := l.untilRun(";}")
+// lexBlock parses CSS blocks found in curly braces. Per css-nesting-1, a run
+// of tokens terminated by '{' rather than ';' or '}' is a nested rule's
+// selector, not a declaration; lexBlock re-enters itself for the rule's
+// nested block, so blockDepth tracks how many '}' are still owed before
+// this block is fully closed. declColonAt is checked first so a
+// declaration whose value itself contains a {} block, e.g. `--foo:
+// {bar};`, isn't mistaken for one of those nested rules.
+func lexBlock(l *Lexer) stateFn {
+	var r rune
+	if l.declColonAt(l.pos) {
+		r = l.untilRunOutsideNested(";", true)
+	} else {
+		r = l.untilRunOutsideNested(";{}", false)
+	}
 	if r == eof {
-		return l.errorf("unclosed block")
+		// The scan above already scanned the rest of input for ';', '{'
+		// and '}' and found none, so there is nothing left to
+		// resynchronize to.
+		return l.errorf(nil, "unclosed block")
 	}
 	defer func() {
 		l.next()
 		l.ignoreSpace()
 	}()
+	if r == openBlock {
+		l.emit(ItemSelector)
+		l.blockDepth++
+		l.emit(ItemBlockStart)
+		return lexBlock
+	}
 	if strings.ContainsRune(l.input[l.start:l.pos], ruleSep) {
 		l.emit(ItemDecl)
 	}
 	if r == closeBlock {
-		l.inBlock = false
+		l.blockDepth--
 		l.emit(ItemBlockEnd)
-		return lexAny
+		if l.blockDepth == 0 {
+			return lexAny
+		}
+		return lexBlock
 	}
 	return lexBlock
 }
 
 // lexAtRuleIdent parses beginning of CSS At-Rule, which starts with '@' char.
-func lexAtRuleIdent(l *lexer) stateFn {
-	i := strings.IndexRune(l.input[l.pos:], ' ')
-	if i < 1 {
-		return l.errorf("missing at-rule ident")
+func lexAtRuleIdent(l *Lexer) stateFn {
+	i := strings.IndexRune(l.input[l.pos+1:], ' ')
+	if i < 0 {
+		// No space anywhere in the rest of input, so this is only ever
+		// detected at EOF; there is nothing to resynchronize to.
+		return l.errorf(nil, "missing at-rule ident")
+	}
+	if i == 0 {
+		// The '@' is immediately followed by a space, e.g. "@ {...}",
+		// leaving no ident at all.
+		return l.errorf(resyncDeclOrAtRule, "missing at-rule ident")
 	}
-	l.pos += i
+	l.advanceTo(l.pos + 1 + i)
 	l.emit(ItemAtRuleIdent)
 	l.ignoreSpace()
 	return lexAtRule
 }
 
 // lexAtRule parses whatever follows after an At-Rule identifier.
-func lexAtRule(l *lexer) stateFn {
-	r := l.untilRun(";{")
+func lexAtRule(l *Lexer) stateFn {
+	r := l.untilRunOutsideNested(";{", false)
 	if r == eof {
-		return l.errorf("missing at-rule body")
+		// untilRunOutsideNested already scanned the rest of input for ';'
+		// and '{' and found neither, so there is nothing left to
+		// resynchronize to.
+		return l.errorf(nil, "missing at-rule body")
 	}
 	defer func() {
 		l.next()
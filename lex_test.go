@@ -41,37 +41,37 @@ body {
 
 func TestLex(t *testing.T) {
 	expItems := []*Item{
-		{ItemAtRuleIdent, 16, "@import"},
-		{ItemAtRule, 24, "url('style.css') print"},
-		{ItemSelector, 48, "body"},
-		{ItemBlockStart, 53, ""},
-		{ItemDecl, 57, "background-color: white"},
-		{ItemDecl, 84, "color: #222"},
-		{ItemBlockEnd, 96, ""},
-		{ItemSelector, 98, "div p"},
-		{ItemSelector, 107, "#id:first-line"},
-		{ItemBlockStart, 122, ""},
-		{ItemDecl, 128, "white-space: nowrap"},
-		{ItemBlockEnd, 149, ""},
-		{ItemAtRuleIdent, 151, "@media"},
-		{ItemAtRuleBlockStart, 158, "print"},
-		{ItemSelector, 168, "body"},
-		{ItemBlockStart, 173, ""},
-		{ItemDecl, 175, "font-size: 10pt"},
-		{ItemBlockEnd, 191, ""},
-		{ItemAtRuleBlockEnd, 194, ""},
-		{ItemSelector, 195, ".c1"},
-		{ItemBlockStart, 198, ""},
-		{ItemDecl, 199, "color:red"},
-		{ItemBlockEnd, 208, ""},
-		{ItemSelector, 209, ".c2"},
-		{ItemBlockStart, 212, ""},
-		{ItemDecl, 213, "color:blue"},
-		{ItemBlockEnd, 223, ""},
-		{ItemSelector, 225, "body"},
-		{ItemBlockStart, 230, ""},
-		{ItemDecl, 234, "background-image: url(data:image/png;base64,iVB)"},
-		{ItemBlockEnd, 284, ""},
+		{ItemAtRuleIdent, Position{16, 3, 1}, 23, "@import"},
+		{ItemAtRule, Position{24, 3, 9}, 46, "url('style.css') print"},
+		{ItemSelector, Position{48, 4, 1}, 52, "body"},
+		{ItemBlockStart, Position{53, 4, 6}, 53, ""},
+		{ItemDecl, Position{57, 5, 3}, 80, "background-color: white"},
+		{ItemDecl, Position{84, 6, 3}, 95, "color: #222"},
+		{ItemBlockEnd, Position{96, 7, 1}, 96, ""},
+		{ItemSelector, Position{98, 8, 1}, 103, "div p"},
+		{ItemSelector, Position{107, 9, 3}, 121, "#id:first-line"},
+		{ItemBlockStart, Position{122, 9, 18}, 122, ""},
+		{ItemDecl, Position{128, 10, 5}, 147, "white-space: nowrap"},
+		{ItemBlockEnd, Position{149, 10, 26}, 149, ""},
+		{ItemAtRuleIdent, Position{151, 11, 1}, 157, "@media"},
+		{ItemAtRuleBlockStart, Position{158, 11, 8}, 163, "print"},
+		{ItemSelector, Position{168, 12, 3}, 172, "body"},
+		{ItemBlockStart, Position{173, 12, 8}, 173, ""},
+		{ItemDecl, Position{175, 12, 10}, 190, "font-size: 10pt"},
+		{ItemBlockEnd, Position{191, 12, 26}, 191, ""},
+		{ItemAtRuleBlockEnd, Position{194, 13, 2}, 194, ""},
+		{ItemSelector, Position{195, 14, 1}, 198, ".c1"},
+		{ItemBlockStart, Position{198, 14, 4}, 198, ""},
+		{ItemDecl, Position{199, 14, 5}, 208, "color:red"},
+		{ItemBlockEnd, Position{208, 14, 14}, 208, ""},
+		{ItemSelector, Position{209, 14, 15}, 212, ".c2"},
+		{ItemBlockStart, Position{212, 14, 18}, 212, ""},
+		{ItemDecl, Position{213, 14, 19}, 223, "color:blue"},
+		{ItemBlockEnd, Position{223, 14, 29}, 223, ""},
+		{ItemSelector, Position{225, 15, 1}, 229, "body"},
+		{ItemBlockStart, Position{230, 15, 6}, 230, ""},
+		{ItemDecl, Position{234, 16, 3}, 282, "background-image: url(data:image/png;base64,iVB)"},
+		{ItemBlockEnd, Position{284, 17, 1}, 284, ""},
 	}
 	i := 0
 	for item := range Lex(validCSS) {
@@ -87,6 +87,293 @@ func TestLex(t *testing.T) {
 	}
 }
 
+func TestLexSeq(t *testing.T) {
+	expItems := []Item{
+		{ItemAtRuleIdent, Position{16, 3, 1}, 23, "@import"},
+		{ItemAtRule, Position{24, 3, 9}, 46, "url('style.css') print"},
+		{ItemSelector, Position{48, 4, 1}, 52, "body"},
+		{ItemBlockStart, Position{53, 4, 6}, 53, ""},
+		{ItemDecl, Position{57, 5, 3}, 80, "background-color: white"},
+		{ItemDecl, Position{84, 6, 3}, 95, "color: #222"},
+		{ItemBlockEnd, Position{96, 7, 1}, 96, ""},
+		{ItemSelector, Position{98, 8, 1}, 103, "div p"},
+		{ItemSelector, Position{107, 9, 3}, 121, "#id:first-line"},
+		{ItemBlockStart, Position{122, 9, 18}, 122, ""},
+		{ItemDecl, Position{128, 10, 5}, 147, "white-space: nowrap"},
+		{ItemBlockEnd, Position{149, 10, 26}, 149, ""},
+		{ItemAtRuleIdent, Position{151, 11, 1}, 157, "@media"},
+		{ItemAtRuleBlockStart, Position{158, 11, 8}, 163, "print"},
+		{ItemSelector, Position{168, 12, 3}, 172, "body"},
+		{ItemBlockStart, Position{173, 12, 8}, 173, ""},
+		{ItemDecl, Position{175, 12, 10}, 190, "font-size: 10pt"},
+		{ItemBlockEnd, Position{191, 12, 26}, 191, ""},
+		{ItemAtRuleBlockEnd, Position{194, 13, 2}, 194, ""},
+		{ItemSelector, Position{195, 14, 1}, 198, ".c1"},
+		{ItemBlockStart, Position{198, 14, 4}, 198, ""},
+		{ItemDecl, Position{199, 14, 5}, 208, "color:red"},
+		{ItemBlockEnd, Position{208, 14, 14}, 208, ""},
+		{ItemSelector, Position{209, 14, 15}, 212, ".c2"},
+		{ItemBlockStart, Position{212, 14, 18}, 212, ""},
+		{ItemDecl, Position{213, 14, 19}, 223, "color:blue"},
+		{ItemBlockEnd, Position{223, 14, 29}, 223, ""},
+		{ItemSelector, Position{225, 15, 1}, 229, "body"},
+		{ItemBlockStart, Position{230, 15, 6}, 230, ""},
+		{ItemDecl, Position{234, 16, 3}, 282, "background-image: url(data:image/png;base64,iVB)"},
+		{ItemBlockEnd, Position{284, 17, 1}, 284, ""},
+	}
+	i := 0
+	for item := range LexSeq(validCSS) {
+		if i > len(expItems)-1 {
+			t.Errorf("%d: unexpected %+v", i, item)
+			break
+		} else if item != expItems[i] {
+			t.Errorf("%d: item = %+v; want %+v", i, item, expItems[i])
+		}
+		i++
+	}
+	if i != len(expItems) {
+		t.Errorf("len(items) = %d; want %d", i, len(expItems))
+	}
+}
+
+func TestLexerNext(t *testing.T) {
+	l := NewLexer(`a { color: red }`)
+	var got []Item
+	for {
+		item, ok := l.Next()
+		if !ok {
+			break
+		}
+		got = append(got, item)
+	}
+	want := []Item{
+		{ItemSelector, Position{0, 1, 1}, 1, "a"},
+		{ItemBlockStart, Position{2, 1, 3}, 2, ""},
+		{ItemDecl, Position{4, 1, 5}, 14, "color: red"},
+		{ItemBlockEnd, Position{15, 1, 16}, 15, ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got = %+v; want %+v", got, want)
+	}
+}
+
+func TestLexerRecover(t *testing.T) {
+	l := NewLexerOptions(`@ nope; a { color: red }`, LexOptions{Recover: true})
+	var got []Item
+	for {
+		item, ok := l.Next()
+		if !ok {
+			break
+		}
+		got = append(got, item)
+	}
+	want := []Item{
+		{ItemError, Position{0, 1, 1}, 0, "missing at-rule ident"},
+		{ItemSelector, Position{8, 1, 9}, 9, "a"},
+		{ItemBlockStart, Position{10, 1, 11}, 10, ""},
+		{ItemDecl, Position{12, 1, 13}, 22, "color: red"},
+		{ItemBlockEnd, Position{23, 1, 24}, 23, ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got = %+v; want %+v", got, want)
+	}
+}
+
+func TestLexerNoRecoverStopsAtFirstError(t *testing.T) {
+	l := NewLexer(`@ nope; a { color: red }`)
+	var got []Item
+	for {
+		item, ok := l.Next()
+		if !ok {
+			break
+		}
+		got = append(got, item)
+	}
+	want := []Item{{ItemError, Position{0, 1, 1}, 0, "missing at-rule ident"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got = %+v; want %+v", got, want)
+	}
+}
+
+func TestLexerNestedRule(t *testing.T) {
+	l := NewLexer(`.card { color:red; &:hover { color:blue } h2 { font-weight:bold } }`)
+	var got []Item
+	for {
+		item, ok := l.Next()
+		if !ok {
+			break
+		}
+		got = append(got, item)
+	}
+	want := []Item{
+		{ItemSelector, Position{0, 1, 1}, 5, ".card"},
+		{ItemBlockStart, Position{6, 1, 7}, 6, ""},
+		{ItemDecl, Position{8, 1, 9}, 17, "color:red"},
+		{ItemSelector, Position{19, 1, 20}, 26, "&:hover"},
+		{ItemBlockStart, Position{27, 1, 28}, 27, ""},
+		{ItemDecl, Position{29, 1, 30}, 39, "color:blue"},
+		{ItemBlockEnd, Position{40, 1, 41}, 40, ""},
+		{ItemSelector, Position{42, 1, 43}, 44, "h2"},
+		{ItemBlockStart, Position{45, 1, 46}, 45, ""},
+		{ItemDecl, Position{47, 1, 48}, 63, "font-weight:bold"},
+		{ItemBlockEnd, Position{64, 1, 65}, 64, ""},
+		{ItemBlockEnd, Position{66, 1, 67}, 66, ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got = %+v; want %+v", got, want)
+	}
+}
+
+// TestLexerDeclWithNestedSemicolon checks that a ';' inside a url(...) or a
+// quoted string, e.g. a data URI, doesn't end the declaration early.
+func TestLexerDeclWithNestedSemicolon(t *testing.T) {
+	l := NewLexer(`a { background: url(data:image/png;base64,iVB); content: "a;b" }`)
+	var got []Item
+	for {
+		item, ok := l.Next()
+		if !ok {
+			break
+		}
+		got = append(got, item)
+	}
+	want := []Item{
+		{ItemSelector, Position{0, 1, 1}, 1, "a"},
+		{ItemBlockStart, Position{2, 1, 3}, 2, ""},
+		{ItemDecl, Position{4, 1, 5}, 46, "background: url(data:image/png;base64,iVB)"},
+		{ItemDecl, Position{48, 1, 49}, 62, `content: "a;b"`},
+		{ItemBlockEnd, Position{63, 1, 64}, 63, ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got = %+v; want %+v", got, want)
+	}
+}
+
+// TestLexerDeclWithEscapedQuote checks that a backslash-escaped quote
+// inside a string doesn't end the string early, so a ';' right after it is
+// still seen as part of the string, not a declaration terminator.
+func TestLexerDeclWithEscapedQuote(t *testing.T) {
+	l := NewLexer(`a { content: "a\"b;c"; color: red }`)
+	var got []Item
+	for {
+		item, ok := l.Next()
+		if !ok {
+			break
+		}
+		got = append(got, item)
+	}
+	want := []Item{
+		{ItemSelector, Position{0, 1, 1}, 1, "a"},
+		{ItemBlockStart, Position{2, 1, 3}, 2, ""},
+		{ItemDecl, Position{4, 1, 5}, 21, `content: "a\"b;c"`},
+		{ItemDecl, Position{23, 1, 24}, 33, "color: red"},
+		{ItemBlockEnd, Position{34, 1, 35}, 34, ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got = %+v; want %+v", got, want)
+	}
+}
+
+// TestLexerAtRuleWithNestedSemicolon checks that an at-rule prelude, not
+// just a declaration, keeps a ';' found inside a quoted url() argument.
+func TestLexerAtRuleWithNestedSemicolon(t *testing.T) {
+	l := NewLexer(`@import url("foo;bar.css");`)
+	var got []Item
+	for {
+		item, ok := l.Next()
+		if !ok {
+			break
+		}
+		got = append(got, item)
+	}
+	want := []Item{
+		{ItemAtRuleIdent, Position{0, 1, 1}, 7, "@import"},
+		{ItemAtRule, Position{8, 1, 9}, 26, `url("foo;bar.css")`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got = %+v; want %+v", got, want)
+	}
+}
+
+// TestLexerSelectorWithNestedComma checks that a ',' inside a functional
+// pseudo-class like :not(...) doesn't split one compound selector in two.
+func TestLexerSelectorWithNestedComma(t *testing.T) {
+	l := NewLexer(`a:not(.foo, .bar) { color: red }`)
+	var got []Item
+	for {
+		item, ok := l.Next()
+		if !ok {
+			break
+		}
+		got = append(got, item)
+	}
+	want := []Item{
+		{ItemSelector, Position{0, 1, 1}, 17, "a:not(.foo, .bar)"},
+		{ItemBlockStart, Position{18, 1, 19}, 18, ""},
+		{ItemDecl, Position{20, 1, 21}, 30, "color: red"},
+		{ItemBlockEnd, Position{31, 1, 32}, 31, ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got = %+v; want %+v", got, want)
+	}
+}
+
+// TestLexerDeclWithBlockValue checks that a declaration whose value itself
+// contains a {} block, e.g. a custom property, isn't mistaken for a nested
+// rule just because its value has a '{' in it.
+func TestLexerDeclWithBlockValue(t *testing.T) {
+	l := NewLexer(`a { --foo: {bar}; color: red; }`)
+	var got []Item
+	for {
+		item, ok := l.Next()
+		if !ok {
+			break
+		}
+		got = append(got, item)
+	}
+	want := []Item{
+		{ItemSelector, Position{0, 1, 1}, 1, "a"},
+		{ItemBlockStart, Position{2, 1, 3}, 2, ""},
+		{ItemDecl, Position{4, 1, 5}, 16, "--foo: {bar}"},
+		{ItemDecl, Position{18, 1, 19}, 28, "color: red"},
+		{ItemBlockEnd, Position{30, 1, 31}, 30, ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got = %+v; want %+v", got, want)
+	}
+}
+
+// TestLexerPosition checks that Line and Col advance correctly across '\n',
+// '\r\n', '\r' and '\f' (all line terminators per CSS Syntax Level 3 section
+// 3.3), including when they appear inside a comment spanning several lines.
+func TestLexerPosition(t *testing.T) {
+	l := NewLexer("a{color:red}\r\nb{color:blue}\rc/* \f */{color:green}")
+	var got []Item
+	for {
+		item, ok := l.Next()
+		if !ok {
+			break
+		}
+		got = append(got, item)
+	}
+	want := []Item{
+		{ItemSelector, Position{0, 1, 1}, 1, "a"},
+		{ItemBlockStart, Position{1, 1, 2}, 1, ""},
+		{ItemDecl, Position{2, 1, 3}, 11, "color:red"},
+		{ItemBlockEnd, Position{11, 1, 12}, 11, ""},
+		{ItemSelector, Position{14, 2, 1}, 15, "b"},
+		{ItemBlockStart, Position{15, 2, 2}, 15, ""},
+		{ItemDecl, Position{16, 2, 3}, 26, "color:blue"},
+		{ItemBlockEnd, Position{26, 2, 13}, 26, ""},
+		{ItemSelector, Position{28, 3, 1}, 36, "c/* \f */"},
+		{ItemBlockStart, Position{36, 4, 4}, 36, ""},
+		{ItemDecl, Position{37, 4, 5}, 48, "color:green"},
+		{ItemBlockEnd, Position{48, 4, 16}, 48, ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got = %+v; want %+v", got, want)
+	}
+}
+
 func ExampleLex() {
 	const cssText = `
 	/* comment **/
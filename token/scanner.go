@@ -0,0 +1,513 @@
+package token
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// replacementChar is substituted for NUL bytes and lone surrogates per the
+// preprocessing step of the CSS Syntax Level 3 spec.
+const replacementChar = '�'
+
+// preprocess applies https://www.w3.org/TR/css-syntax-3/#input-preprocessing:
+// CR, FF and CRLF are normalized to a single LF, and NUL (and, since Go
+// strings are already valid UTF-8, any code point that cannot occur, such as
+// a lone surrogate) is replaced with U+FFFD.
+func preprocess(input string) string {
+	if strings.IndexAny(input, "\r\f\x00") < 0 {
+		return input
+	}
+	var b strings.Builder
+	b.Grow(len(input))
+	for i := 0; i < len(input); i++ {
+		switch c := input[i]; c {
+		case '\r':
+			if i+1 < len(input) && input[i+1] == '\n' {
+				i++
+			}
+			b.WriteByte('\n')
+		case '\f':
+			b.WriteByte('\n')
+		case 0:
+			b.WriteRune(replacementChar)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// scanner turns preprocessed CSS source into a sequence of Tokens following
+// the "consume a token" algorithm from CSS Syntax Level 3 section 4.3.
+type scanner struct {
+	input string
+	pos   int // byte offset of the next unread rune
+}
+
+func newScanner(input string) *scanner {
+	return &scanner{input: preprocess(input)}
+}
+
+const eof = -1
+
+// peekN returns the rune n code points ahead of pos without consuming
+// anything, and eof if the input ends before then.
+func (s *scanner) peekN(n int) rune {
+	pos := s.pos
+	var r rune
+	for i := 0; i <= n; i++ {
+		if pos >= len(s.input) {
+			return eof
+		}
+		var w int
+		r, w = utf8.DecodeRuneInString(s.input[pos:])
+		pos += w
+	}
+	return r
+}
+
+func (s *scanner) peek() rune { return s.peekN(0) }
+
+// next consumes and returns the next rune, or eof.
+func (s *scanner) next() rune {
+	if s.pos >= len(s.input) {
+		return eof
+	}
+	r, w := utf8.DecodeRuneInString(s.input[s.pos:])
+	s.pos += w
+	return r
+}
+
+func (s *scanner) hasPrefix(p string) bool {
+	return strings.HasPrefix(s.input[s.pos:], p)
+}
+
+func isDigit(r rune) bool { return r >= '0' && r <= '9' }
+
+func isHexDigit(r rune) bool {
+	return isDigit(r) || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
+}
+
+func isNameStart(r rune) bool {
+	return r == '_' || r >= 0x80 ||
+		(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func isName(r rune) bool { return isNameStart(r) || isDigit(r) || r == '-' }
+
+func isNewline(r rune) bool { return r == '\n' }
+
+func isWhitespace(r rune) bool { return r == '\n' || r == '\t' || r == ' ' }
+
+// Next consumes and returns the next Token, reporting EOF once the input is
+// exhausted. Comments are consumed silently, as the spec requires.
+func (s *scanner) Next() *Token {
+	for s.hasPrefix("/*") {
+		s.consumeComment()
+	}
+	pos := s.pos
+	r := s.next()
+	tok := s.consumeToken(r)
+	tok.Pos = pos
+	return tok
+}
+
+// consumeComment consumes a /* ... */ comment, tolerating one left
+// unterminated at EOF.
+func (s *scanner) consumeComment() {
+	s.pos += len("/*")
+	if i := strings.Index(s.input[s.pos:], "*/"); i >= 0 {
+		s.pos += i + len("*/")
+	} else {
+		s.pos = len(s.input)
+	}
+}
+
+func (s *scanner) consumeToken(r rune) *Token {
+	switch {
+	case r == eof:
+		return &Token{Type: EOF}
+	case isWhitespace(r):
+		for isWhitespace(s.peek()) {
+			s.next()
+		}
+		return &Token{Type: Whitespace}
+	case r == '"', r == '\'':
+		return s.consumeString(r)
+	case r == '#':
+		if isName(s.peek()) || s.validEscape(s.peek(), s.peekN(1)) {
+			flag := HashUnrestricted
+			if s.wouldStartIdent(s.peek(), s.peekN(1), s.peekN(2)) {
+				flag = HashID
+			}
+			return &Token{Type: Hash, Value: s.consumeName(), HashType: flag}
+		}
+		return &Token{Type: Delim, Value: string(r)}
+	case r == '$':
+		if s.peek() == '=' {
+			s.next()
+			return &Token{Type: SuffixMatch}
+		}
+		return &Token{Type: Delim, Value: string(r)}
+	case r == '(':
+		return &Token{Type: LeftParen}
+	case r == ')':
+		return &Token{Type: RightParen}
+	case r == '*':
+		if s.peek() == '=' {
+			s.next()
+			return &Token{Type: SubstringMatch}
+		}
+		return &Token{Type: Delim, Value: string(r)}
+	case r == '+':
+		if s.startsNumber(r, s.peek(), s.peekN(1)) {
+			s.pos -= utf8.RuneLen(r)
+			return s.consumeNumeric()
+		}
+		return &Token{Type: Delim, Value: string(r)}
+	case r == ',':
+		return &Token{Type: Comma}
+	case r == '-':
+		if s.startsNumber(r, s.peek(), s.peekN(1)) {
+			s.pos -= utf8.RuneLen(r)
+			return s.consumeNumeric()
+		}
+		if s.peek() == '-' && s.peekN(1) == '>' {
+			s.next()
+			s.next()
+			return &Token{Type: CDC}
+		}
+		if s.wouldStartIdent(r, s.peek(), s.peekN(1)) {
+			s.pos -= utf8.RuneLen(r)
+			return s.consumeIdentLike()
+		}
+		return &Token{Type: Delim, Value: string(r)}
+	case r == '.':
+		if s.startsNumber(r, s.peek(), s.peekN(1)) {
+			s.pos -= utf8.RuneLen(r)
+			return s.consumeNumeric()
+		}
+		return &Token{Type: Delim, Value: string(r)}
+	case r == ':':
+		return &Token{Type: Colon}
+	case r == ';':
+		return &Token{Type: Semicolon}
+	case r == '<':
+		if s.hasPrefix("!--") {
+			s.pos += len("!--")
+			return &Token{Type: CDO}
+		}
+		return &Token{Type: Delim, Value: string(r)}
+	case r == '@':
+		if s.wouldStartIdent(s.peek(), s.peekN(1), s.peekN(2)) {
+			return &Token{Type: AtKeyword, Value: s.consumeName()}
+		}
+		return &Token{Type: Delim, Value: string(r)}
+	case r == '[':
+		return &Token{Type: LeftBracket}
+	case r == '\\':
+		if s.validEscape(r, s.peek()) {
+			s.pos -= utf8.RuneLen(r)
+			return s.consumeIdentLike()
+		}
+		return &Token{Type: Delim, Value: string(r)}
+	case r == ']':
+		return &Token{Type: RightBracket}
+	case r == '^':
+		if s.peek() == '=' {
+			s.next()
+			return &Token{Type: PrefixMatch}
+		}
+		return &Token{Type: Delim, Value: string(r)}
+	case r == '{':
+		return &Token{Type: LeftBrace}
+	case r == '}':
+		return &Token{Type: RightBrace}
+	case isDigit(r):
+		s.pos -= utf8.RuneLen(r)
+		return s.consumeNumeric()
+	case (r == 'u' || r == 'U') && s.peek() == '+' && (isHexDigit(s.peekN(1)) || s.peekN(1) == '?'):
+		return s.consumeUnicodeRange()
+	case isNameStart(r):
+		s.pos -= utf8.RuneLen(r)
+		return s.consumeIdentLike()
+	case r == '|':
+		if s.peek() == '=' {
+			s.next()
+			return &Token{Type: DashMatch}
+		}
+		if s.peek() == '|' {
+			s.next()
+			return &Token{Type: Column}
+		}
+		return &Token{Type: Delim, Value: string(r)}
+	case r == '~':
+		if s.peek() == '=' {
+			s.next()
+			return &Token{Type: IncludeMatch}
+		}
+		return &Token{Type: Delim, Value: string(r)}
+	default:
+		return &Token{Type: Delim, Value: string(r)}
+	}
+}
+
+// validEscape reports whether a and b form a valid escape per
+// https://www.w3.org/TR/css-syntax-3/#starts-with-a-valid-escape: a is a
+// backslash not followed by a newline or EOF.
+func (s *scanner) validEscape(a, b rune) bool {
+	return a == '\\' && b != eof && !isNewline(b)
+}
+
+// wouldStartIdent reports whether the three code points a, b, c would begin
+// an identifier, per
+// https://www.w3.org/TR/css-syntax-3/#would-start-an-identifier.
+func (s *scanner) wouldStartIdent(a, b, c rune) bool {
+	switch {
+	case a == '-':
+		return isNameStart(b) || b == '-' || s.validEscape(b, c)
+	case isNameStart(a):
+		return true
+	case a == '\\':
+		return s.validEscape(a, b)
+	default:
+		return false
+	}
+}
+
+// startsNumber reports whether a, b, c would begin a number, per
+// https://www.w3.org/TR/css-syntax-3/#starts-with-a-number.
+func (s *scanner) startsNumber(a, b, c rune) bool {
+	switch {
+	case a == '+' || a == '-':
+		return isDigit(b) || (b == '.' && isDigit(c))
+	case a == '.':
+		return isDigit(b)
+	default:
+		return isDigit(a)
+	}
+}
+
+// consumeName implements "consume a name": as many name code points and
+// valid escapes as possible, already unescaped.
+func (s *scanner) consumeName() string {
+	var b strings.Builder
+	for {
+		r := s.peek()
+		if isName(r) {
+			s.next()
+			b.WriteRune(r)
+			continue
+		}
+		if s.validEscape(r, s.peekN(1)) {
+			s.next()
+			b.WriteRune(s.consumeEscaped())
+			continue
+		}
+		return b.String()
+	}
+}
+
+// consumeEscaped implements "consume an escaped code point". The leading
+// backslash must already be consumed.
+func (s *scanner) consumeEscaped() rune {
+	r := s.next()
+	if r == eof {
+		return replacementChar
+	}
+	if !isHexDigit(r) {
+		return r
+	}
+	hex := string(r)
+	for i := 0; i < 5 && isHexDigit(s.peek()); i++ {
+		hex += string(s.next())
+	}
+	if isWhitespace(s.peek()) {
+		s.next()
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || v == 0 || v > utf8.MaxRune || (v >= 0xD800 && v <= 0xDFFF) {
+		return replacementChar
+	}
+	return rune(v)
+}
+
+// consumeString implements "consume a string token" for the given quote.
+func (s *scanner) consumeString(quote rune) *Token {
+	var b strings.Builder
+	for {
+		r := s.next()
+		switch {
+		case r == eof || r == quote:
+			return &Token{Type: String, Value: b.String()}
+		case isNewline(r):
+			s.pos -= utf8.RuneLen(r)
+			return &Token{Type: BadString, Value: b.String()}
+		case r == '\\':
+			if s.peek() == eof {
+				continue
+			}
+			if isNewline(s.peek()) {
+				s.next()
+				continue
+			}
+			b.WriteRune(s.consumeEscaped())
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+// consumeNumeric implements "consume a numeric token".
+func (s *scanner) consumeNumeric() *Token {
+	value, isInt := s.consumeNumber()
+	if s.wouldStartIdent(s.peek(), s.peekN(1), s.peekN(2)) {
+		return &Token{Type: Dimension, Number: value, IsInt: isInt, Unit: s.consumeName()}
+	}
+	if s.peek() == '%' {
+		s.next()
+		return &Token{Type: Percentage, Number: value, IsInt: isInt}
+	}
+	return &Token{Type: Number, Number: value, IsInt: isInt}
+}
+
+// consumeNumber implements "consume a number", returning its numeric value
+// and whether it had the "integer" type flag.
+func (s *scanner) consumeNumber() (value float64, isInt bool) {
+	var b strings.Builder
+	isInt = true
+	if s.peek() == '+' || s.peek() == '-' {
+		b.WriteRune(s.next())
+	}
+	for isDigit(s.peek()) {
+		b.WriteRune(s.next())
+	}
+	if s.peek() == '.' && isDigit(s.peekN(1)) {
+		isInt = false
+		b.WriteRune(s.next())
+		for isDigit(s.peek()) {
+			b.WriteRune(s.next())
+		}
+	}
+	if s.peek() == 'e' || s.peek() == 'E' {
+		la, lb := s.peekN(1), s.peekN(2)
+		if isDigit(la) || ((la == '+' || la == '-') && isDigit(lb)) {
+			isInt = false
+			b.WriteRune(s.next())
+			if s.peek() == '+' || s.peek() == '-' {
+				b.WriteRune(s.next())
+			}
+			for isDigit(s.peek()) {
+				b.WriteRune(s.next())
+			}
+		}
+	}
+	value, _ = strconv.ParseFloat(b.String(), 64)
+	return value, isInt
+}
+
+// consumeIdentLike implements "consume an ident-like token", which may
+// produce an Ident, Function, URL or BadURL token.
+func (s *scanner) consumeIdentLike() *Token {
+	name := s.consumeName()
+	if strings.EqualFold(name, "url") && s.peek() == '(' {
+		s.next()
+		for isWhitespace(s.peek()) && isWhitespace(s.peekN(1)) {
+			s.next()
+		}
+		if s.peek() == '"' || s.peek() == '\'' ||
+			(isWhitespace(s.peek()) && (s.peekN(1) == '"' || s.peekN(1) == '\'')) {
+			return &Token{Type: Function, Value: name}
+		}
+		return s.consumeURL()
+	}
+	if s.peek() == '(' {
+		s.next()
+		return &Token{Type: Function, Value: name}
+	}
+	return &Token{Type: Ident, Value: name}
+}
+
+// consumeURL implements "consume a url token", called once the leading
+// "url(" has already been consumed and the remainder is known not to start
+// a quoted string argument.
+func (s *scanner) consumeURL() *Token {
+	var b strings.Builder
+	for isWhitespace(s.peek()) {
+		s.next()
+	}
+	for {
+		r := s.next()
+		switch {
+		case r == ')' || r == eof:
+			return &Token{Type: URL, Value: b.String()}
+		case isWhitespace(r):
+			for isWhitespace(s.peek()) {
+				s.next()
+			}
+			if s.peek() == ')' || s.peek() == eof {
+				s.next()
+				return &Token{Type: URL, Value: b.String()}
+			}
+			return s.consumeBadURL()
+		case r == '"', r == '\'', r == '(':
+			return s.consumeBadURL()
+		case r == '\\':
+			if s.validEscape(r, s.peek()) {
+				b.WriteRune(s.consumeEscaped())
+				continue
+			}
+			return s.consumeBadURL()
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+// consumeBadURL implements "consume the remnants of a bad url".
+func (s *scanner) consumeBadURL() *Token {
+	for {
+		r := s.next()
+		switch {
+		case r == ')' || r == eof:
+			return &Token{Type: BadURL}
+		case s.validEscape(r, s.peek()):
+			s.consumeEscaped()
+		}
+	}
+}
+
+// consumeUnicodeRange implements the unicode-range extension described by
+// https://www.w3.org/TR/css-syntax-3/#urange, invoked once "u+" has been
+// recognized (the "u" already consumed, "+" not yet).
+func (s *scanner) consumeUnicodeRange() *Token {
+	s.next() // '+'
+	var digits string
+	for len(digits) < 6 && isHexDigit(s.peek()) {
+		digits += string(s.next())
+	}
+	var wildcards int
+	for len(digits)+wildcards < 6 && s.peek() == '?' {
+		s.next()
+		wildcards++
+	}
+	if wildcards > 0 {
+		lo := digits + strings.Repeat("0", wildcards)
+		hi := digits + strings.Repeat("f", wildcards)
+		start, _ := strconv.ParseUint(lo, 16, 32)
+		end, _ := strconv.ParseUint(hi, 16, 32)
+		return &Token{Type: UnicodeRange, RangeStart: rune(start), RangeEnd: rune(end)}
+	}
+	start, _ := strconv.ParseUint(digits, 16, 32)
+	end := start
+	if s.peek() == '-' && isHexDigit(s.peekN(1)) {
+		s.next()
+		var hi string
+		for len(hi) < 6 && isHexDigit(s.peek()) {
+			hi += string(s.next())
+		}
+		end, _ = strconv.ParseUint(hi, 16, 32)
+	}
+	return &Token{Type: UnicodeRange, RangeStart: rune(start), RangeEnd: rune(end)}
+}
@@ -0,0 +1,194 @@
+// Package token implements the tokenizer described by the CSS Syntax
+// Module Level 3 spec (https://www.w3.org/TR/css-syntax-3/#tokenization).
+//
+// It is a lower-level, spec-conformant alternative to the coarse-grained
+// items produced by the top-level csslex package: every code point of the
+// input outside comments is accounted for by exactly one Token, including
+// whitespace and punctuation that csslex.Lex discards. Comments produce no
+// Token.
+package token
+
+import "fmt"
+
+// Type identifies the kind of a Token, as enumerated by CSS Syntax Level 3
+// section 4 (https://www.w3.org/TR/css-syntax-3/#tokenizer-definitions).
+type Type int
+
+// Token types from the CSS Syntax Level 3 tokenizer.
+const (
+	EOF Type = iota
+	Ident
+	Function
+	AtKeyword
+	Hash
+	String
+	BadString
+	URL
+	BadURL
+	Delim
+	Number
+	Percentage
+	Dimension
+	UnicodeRange
+	IncludeMatch   // ~=
+	DashMatch      // |=
+	PrefixMatch    // ^=
+	SuffixMatch    // $=
+	SubstringMatch // *=
+	Column         // ||
+	Whitespace
+	CDO // <!--
+	CDC // -->
+	Colon
+	Semicolon
+	Comma
+	LeftBracket  // [
+	RightBracket // ]
+	LeftParen    // (
+	RightParen   // )
+	LeftBrace    // {
+	RightBrace   // }
+)
+
+func (t Type) String() string {
+	switch t {
+	case EOF:
+		return "EOF"
+	case Ident:
+		return "Ident"
+	case Function:
+		return "Function"
+	case AtKeyword:
+		return "AtKeyword"
+	case Hash:
+		return "Hash"
+	case String:
+		return "String"
+	case BadString:
+		return "BadString"
+	case URL:
+		return "URL"
+	case BadURL:
+		return "BadURL"
+	case Delim:
+		return "Delim"
+	case Number:
+		return "Number"
+	case Percentage:
+		return "Percentage"
+	case Dimension:
+		return "Dimension"
+	case UnicodeRange:
+		return "UnicodeRange"
+	case IncludeMatch:
+		return "IncludeMatch"
+	case DashMatch:
+		return "DashMatch"
+	case PrefixMatch:
+		return "PrefixMatch"
+	case SuffixMatch:
+		return "SuffixMatch"
+	case SubstringMatch:
+		return "SubstringMatch"
+	case Column:
+		return "Column"
+	case Whitespace:
+		return "Whitespace"
+	case CDO:
+		return "CDO"
+	case CDC:
+		return "CDC"
+	case Colon:
+		return "Colon"
+	case Semicolon:
+		return "Semicolon"
+	case Comma:
+		return "Comma"
+	case LeftBracket:
+		return "LeftBracket"
+	case RightBracket:
+		return "RightBracket"
+	case LeftParen:
+		return "LeftParen"
+	case RightParen:
+		return "RightParen"
+	case LeftBrace:
+		return "LeftBrace"
+	case RightBrace:
+		return "RightBrace"
+	default:
+		return fmt.Sprintf("Type(%d)", int(t))
+	}
+}
+
+// HashFlag distinguishes the two flavors of Hash token defined by the spec:
+// a Hash whose value would be a valid identifier (HashID), used for ID
+// selectors and references, versus any other Hash (HashUnrestricted), such
+// as a hex color.
+type HashFlag int
+
+const (
+	HashUnrestricted HashFlag = iota
+	HashID
+)
+
+// Token is a single lexical unit of the CSS Syntax Level 3 tokenizer.
+//
+// Not every field applies to every Type; see the comment on each field for
+// which Types populate it.
+type Token struct {
+	Type Type
+
+	// Pos is the byte offset of the first rune of the token in the
+	// preprocessed input, in which CR, FF and CRLF are each replaced by a
+	// single LF and NUL by U+FFFD.
+	Pos int
+
+	// Value holds:
+	//   - the unescaped name for Ident, Function, AtKeyword and Hash;
+	//   - the unescaped contents for String, BadString and URL;
+	//   - the single rune, as a string, for Delim.
+	// It is empty for token types that carry no text, including BadURL.
+	Value string
+
+	// Unit is the unescaped unit of a Dimension token, e.g. "px" or "em".
+	// A trailing "%" produces a Percentage token instead, with no Unit.
+	Unit string
+
+	// Number is the numeric value of a Number, Percentage or Dimension
+	// token, already converted per the spec's "convert a string to a
+	// number" algorithm.
+	Number float64
+
+	// IsInt reports whether a Number, Percentage or Dimension token was
+	// written without a fractional part or exponent, i.e. its type flag
+	// is "integer" rather than "number".
+	IsInt bool
+
+	// HashType applies to Hash tokens only.
+	HashType HashFlag
+
+	// RangeStart and RangeEnd are the bounds of a UnicodeRange token,
+	// e.g. U+0-7F for "U+0-7F".
+	RangeStart, RangeEnd rune
+}
+
+// String returns a human-readable representation of t, primarily useful
+// for debugging and test failure messages.
+func (t *Token) String() string {
+	switch t.Type {
+	case Dimension:
+		return fmt.Sprintf("%s(%g%s)", t.Type, t.Number, t.Unit)
+	case Number, Percentage:
+		return fmt.Sprintf("%s(%g)", t.Type, t.Number)
+	case UnicodeRange:
+		return fmt.Sprintf("%s(%#x-%#x)", t.Type, t.RangeStart, t.RangeEnd)
+	case Whitespace, Colon, Semicolon, Comma,
+		LeftBracket, RightBracket, LeftParen, RightParen, LeftBrace, RightBrace,
+		CDO, CDC, EOF,
+		IncludeMatch, DashMatch, PrefixMatch, SuffixMatch, SubstringMatch, Column:
+		return t.Type.String()
+	default:
+		return fmt.Sprintf("%s(%q)", t.Type, t.Value)
+	}
+}
@@ -0,0 +1,126 @@
+package token
+
+import (
+	"reflect"
+	"testing"
+)
+
+func collect(input string) []*Token {
+	var got []*Token
+	for tok := range Tokenize(input) {
+		got = append(got, tok)
+		if tok.Type == EOF {
+			break
+		}
+	}
+	return got
+}
+
+func TestTokenizeSimpleRule(t *testing.T) {
+	const css = `a.c1 { color: #fff; width: 2.5px }`
+	want := []*Token{
+		{Type: Ident, Pos: 0, Value: "a"},
+		{Type: Delim, Pos: 1, Value: "."},
+		{Type: Ident, Pos: 2, Value: "c1"},
+		{Type: Whitespace, Pos: 4},
+		{Type: LeftBrace, Pos: 5},
+		{Type: Whitespace, Pos: 6},
+		{Type: Ident, Pos: 7, Value: "color"},
+		{Type: Colon, Pos: 12},
+		{Type: Whitespace, Pos: 13},
+		{Type: Hash, Pos: 14, Value: "fff", HashType: HashID},
+		{Type: Semicolon, Pos: 18},
+		{Type: Whitespace, Pos: 19},
+		{Type: Ident, Pos: 20, Value: "width"},
+		{Type: Colon, Pos: 25},
+		{Type: Whitespace, Pos: 26},
+		{Type: Dimension, Pos: 27, Number: 2.5, Unit: "px"},
+		{Type: Whitespace, Pos: 32},
+		{Type: RightBrace, Pos: 33},
+		{Type: EOF, Pos: 34},
+	}
+	if got := collect(css); !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize(%q) =\n%v\nwant\n%v", css, got, want)
+	}
+}
+
+func TestTokenizeIDHash(t *testing.T) {
+	got := collect("#id1 #1")
+	want := []*Token{
+		{Type: Hash, Pos: 0, Value: "id1", HashType: HashID},
+		{Type: Whitespace, Pos: 4},
+		{Type: Hash, Pos: 5, Value: "1", HashType: HashUnrestricted},
+		{Type: EOF, Pos: 7},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize = %v; want %v", got, want)
+	}
+}
+
+func TestTokenizeCustomProperty(t *testing.T) {
+	got := collect("--main-color")
+	want := []*Token{
+		{Type: Ident, Pos: 0, Value: "--main-color"},
+		{Type: EOF, Pos: 12},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize = %v; want %v", got, want)
+	}
+}
+
+func TestTokenizeURL(t *testing.T) {
+	tests := []struct{ css string }{
+		{`url(foo.png)`},
+		{`url( foo.png )`},
+		{`url("foo.png")`},
+		{`url( "foo.png")`},
+		{`url(   "foo.png")`},
+	}
+	for _, tt := range tests {
+		got := collect(tt.css)
+		if len(got) == 0 || (got[0].Type != URL && got[0].Type != Function) {
+			t.Errorf("Tokenize(%q)[0] = %v; want URL or Function", tt.css, got)
+		}
+	}
+	got := collect(`url(bad "quote" inside)`)
+	if got[0].Type != BadURL {
+		t.Errorf("Tokenize(bad url)[0].Type = %v; want BadURL", got[0].Type)
+	}
+}
+
+func TestTokenizeEscapes(t *testing.T) {
+	got := collect(`"a\62 c"`)
+	want := []*Token{
+		{Type: String, Pos: 0, Value: "abc"},
+		{Type: EOF, Pos: 8},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize = %v; want %v", got, want)
+	}
+}
+
+func TestTokenizeUnicodeRange(t *testing.T) {
+	got := collect("U+0025-00FF")
+	want := []*Token{
+		{Type: UnicodeRange, Pos: 0, RangeStart: 0x25, RangeEnd: 0xFF},
+		{Type: EOF, Pos: 11},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize = %v; want %v", got, want)
+	}
+}
+
+func TestTokenizeMatchOperators(t *testing.T) {
+	got := collect(`~= |= ^= $= *= ||`)
+	var typs []Type
+	for _, tok := range got {
+		typs = append(typs, tok.Type)
+	}
+	want := []Type{
+		IncludeMatch, Whitespace, DashMatch, Whitespace, PrefixMatch, Whitespace,
+		SuffixMatch, Whitespace, SubstringMatch, Whitespace, Column, EOF,
+	}
+	if !reflect.DeepEqual(typs, want) {
+		t.Errorf("types = %v; want %v", typs, want)
+	}
+}
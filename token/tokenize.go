@@ -0,0 +1,25 @@
+package token
+
+import "iter"
+
+// Tokenize returns a pull-based iterator over the Tokens of input, per the
+// CSS Syntax Level 3 tokenizer (https://www.w3.org/TR/css-syntax-3/#tokenization).
+// The final Token yielded always has Type EOF.
+//
+// Unlike csslex.Lex, Tokenize does no work until it is driven and allocates
+// no goroutine or channel: it is a plain range-over-func iterator, so a
+// range loop that returns early leaves nothing behind to clean up.
+func Tokenize(input string) iter.Seq[*Token] {
+	return func(yield func(*Token) bool) {
+		s := newScanner(input)
+		for {
+			tok := s.Next()
+			if !yield(tok) {
+				return
+			}
+			if tok.Type == EOF {
+				return
+			}
+		}
+	}
+}